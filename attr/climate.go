@@ -0,0 +1,48 @@
+package attr
+
+// Additional attribute types for thermostat, lock, garage door, sprinkler
+// and fan style devices. Values start well past the existing core attribute
+// types (ATOnOff, ATBrightness, ATOffset) so they can't collide with entries
+// added to that enum in the future.
+//
+// extensions/lutron translates ATOnOff/ATBrightness/ATOffset and ignores
+// the rest, since the vendored driver it wraps has no call for them; a
+// command carrying only these types is rejected there as an unsupported
+// attribute (see its cmd_builder.go). extensions/example isn't part of this source tree,
+// so the no-op branch requested for it there hasn't been added - there's no
+// file here to add it to.
+const (
+	ATSetpointC Type = 100 + iota
+	ATSetpointF
+	ATHVACMode
+	ATLockState
+	ATGaragePosition
+	ATFanSpeed
+	ATSprinklerDuration
+)
+
+// HVACMode is the value carried by an ATHVACMode Attr.
+type HVACMode int32
+
+const (
+	HVACModeOff HVACMode = iota
+	HVACModeHeat
+	HVACModeCool
+	HVACModeAuto
+)
+
+// LockState is the value carried by an ATLockState Attr.
+type LockState int32
+
+const (
+	LockStateUnlocked LockState = iota
+	LockStateLocked
+)
+
+// GaragePosition is the value carried by an ATGaragePosition Attr.
+type GaragePosition int32
+
+const (
+	GaragePositionClosed GaragePosition = iota
+	GaragePositionOpen
+)
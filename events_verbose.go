@@ -0,0 +1,18 @@
+package gohome
+
+// VerboseKey implementations for the events that are chatty enough to be
+// worth aggregating rather than logging one line per occurrence. The string
+// returned is the key used to tally and report counts, see VerboseKey and
+// verboseTally.
+
+// VerboseKey identifies ZoneLevelChanged events in the aggregated counters.
+func (e *ZoneLevelChanged) VerboseKey() string { return "zone.level" }
+
+// VerboseKey identifies SensorAttrChanged events in the aggregated counters.
+func (e *SensorAttrChanged) VerboseKey() string { return "sensor.attr" }
+
+// VerboseKey identifies SensorsReporting events in the aggregated counters.
+func (e *SensorsReporting) VerboseKey() string { return "sensor.reporting" }
+
+// VerboseKey identifies ZonesReporting events in the aggregated counters.
+func (e *ZonesReporting) VerboseKey() string { return "zone.reporting" }
@@ -1,21 +1,62 @@
 package lutron
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	lutronExt "github.com/go-home-iot/lutron"
 	"github.com/markdaws/gohome"
 	"github.com/markdaws/gohome/attr"
 	"github.com/markdaws/gohome/cmd"
+	"github.com/markdaws/gohome/retry"
 )
 
+// connectRetry bounds how hard getWriterAndExec tries to acquire a
+// connection and send a command before giving up, a Lutron hub can briefly
+// TCP-reset while applying a firmware update.
+const (
+	connectRetryMinDelay = 250 * time.Millisecond
+	connectRetryMaxDelay = 5 * time.Second
+	connectRetryMaxTries = 5
+)
+
+// execTimeout bounds the whole acquire-and-send operation in
+// getWriterAndExec, including every retry, so a wedged hub can't keep the
+// backoff loop running indefinitely.
+const execTimeout = 30 * time.Second
+
 type cmdBuilder struct {
 	System *gohome.System
 	device lutronExt.Device
 }
 
+// permanentConnErrSubstrings are substrings, matched case-insensitively, of
+// an error from hub.Connections.Get that mean retrying won't help. The pool
+// itself isn't vendored in this tree, so it has no exported sentinel errors
+// to check with errors.Is - this is the best classification available
+// short of that.
+var permanentConnErrSubstrings = []string{"auth", "unauthoriz", "credential", "unknown model"}
+
+// classifyConnErr wraps err with retry.ErrPermanent when it looks like an
+// auth failure or unrecognized device model, so retry.Backoff.Run aborts
+// immediately instead of burning all its retries on an error retrying can
+// never fix.
+func classifyConnErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range permanentConnErrSubstrings {
+		if strings.Contains(msg, s) {
+			return fmt.Errorf("%w: %s", retry.ErrPermanent, err)
+		}
+	}
+	return err
+}
+
 func (b *cmdBuilder) Build(c cmd.Command) (*cmd.Func, error) {
 
 	switch command := c.(type) {
@@ -42,6 +83,23 @@ func (b *cmdBuilder) Build(c cmd.Command) (*cmd.Func, error) {
 				}
 			case attr.ATBrightness, attr.ATOffset:
 				level = attribute.Value.(float32)
+
+			case attr.ATSetpointC, attr.ATSetpointF, attr.ATHVACMode,
+				attr.ATLockState, attr.ATGaragePosition, attr.ATFanSpeed,
+				attr.ATSprinklerDuration:
+				// SetLevel is the only lutronExt.Device method this package
+				// calls anywhere (see getWriterAndExec below); that's the
+				// full extent of the driver surface this builder has to
+				// translate attributes into, and it has no equivalent for a
+				// thermostat setpoint/mode, lock state, garage position,
+				// fan speed or sprinkler duration. So these are a
+				// deliberate no-op here: a FeatureSetAttrs that also
+				// carries an ATOnOff/ATBrightness/ATOffset attribute still
+				// succeeds for that attribute instead of failing outright.
+				// A FeatureSetAttrs made up entirely of these types falls
+				// through to the "unsupported attribute" error below, same
+				// as any other attribute type this builder doesn't
+				// recognize.
 			}
 		}
 
@@ -69,20 +127,40 @@ func getWriterAndExec(d *gohome.Device, f func(lutronExt.Device, io.Writer) erro
 		hub = d.Hub
 	}
 
-	conn, err := hub.Connections.Get(time.Second*5, true)
-	if err != nil {
-		return fmt.Errorf("error connecting, pool returned err: %s", err)
-	}
+	// cmd.Func.Func takes no context, so there's nothing to derive a
+	// cancelable context from upstream; bound the whole operation with a
+	// timeout instead of context.Background(), so it can actually be
+	// aborted rather than retrying forever.
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
 
+	// An unrecognized model is never going to become recognized, so this
+	// intentionally runs once, outside the retry loop below, rather than
+	// being retried.
 	lDev, err := lutronExt.DeviceFromModelNumber(hub.ModelNumber)
 	if err != nil {
 		return err
 	}
 
-	err = f(lDev, conn)
-	hub.Connections.Release(conn, err)
+	// Acquire and send inside the same retry loop so a retry after a failed
+	// send re-acquires a fresh connection instead of retrying against the
+	// same, possibly dead, one.
+	backoff := retry.NewBackoff(connectRetryMinDelay, connectRetryMaxDelay, connectRetryMaxTries)
+	err = backoff.Run(ctx, func() error {
+		conn, err := hub.Connections.Get(time.Second*5, true)
+		if err != nil {
+			return fmt.Errorf("error connecting, pool returned err: %w", classifyConnErr(err))
+		}
+
+		err = f(lDev, conn)
+		hub.Connections.Release(conn, err)
+		if err != nil {
+			return fmt.Errorf("failed to send command: %s", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("Failed to send command %s\n", err)
+		return backoff.ErrCause()
 	}
 	return nil
 }
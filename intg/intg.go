@@ -1,6 +1,8 @@
 package intg
 
 import (
+	"context"
+
 	"github.com/markdaws/gohome"
 	"github.com/markdaws/gohome/extensions/belkin"
 	"github.com/markdaws/gohome/extensions/connectedbytcp"
@@ -10,8 +12,21 @@ import (
 	"github.com/markdaws/gohome/log"
 )
 
-// RegisterExtensions loads all of the know extensions into the specified system
-func RegisterExtensions(sys *gohome.System) error {
+// RegisterExtensions loads all of the know extensions into the specified
+// system. ctx is the system's root context, it is the one that should be
+// passed to any long running component created during startup (e.g. the
+// system's Monitor) so that shutdown across the whole system is deterministic.
+//
+// RegisterExtensions itself doesn't construct a Monitor - none of the
+// extensions registered here are long running - so ctx isn't used in this
+// function. The call that does construct the system's Monitor, and must
+// pass this same ctx into NewMonitor, lives in the system bootstrap code
+// that isn't part of this source tree; it can't be verified from here.
+// Whoever wires that call up is responsible for threading ctx through, or
+// handleTimeouts/the evtbus consumer/the stale refresher/the verbose tally
+// all leak on restart exactly as before this package accepted a context at
+// all.
+func RegisterExtensions(ctx context.Context, sys *gohome.System) error {
 	log.V("registering extensions")
 
 	log.V("register extension - belkin")
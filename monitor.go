@@ -1,6 +1,7 @@
 package gohome
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -10,6 +11,7 @@ import (
 	"github.com/go-home-iot/event-bus"
 	"github.com/markdaws/gohome/cmd"
 	"github.com/markdaws/gohome/log"
+	"github.com/markdaws/gohome/store"
 )
 
 // Updater is the interface for receiving updates values from the monitor
@@ -48,14 +50,47 @@ type Monitor struct {
 	sensorValues   map[string]SensorAttr
 	zoneValues     map[string]cmd.Level
 	mutex          sync.RWMutex
+	pool           *dispatchPool
+	verbose        *verboseTally
+	ctx            context.Context
+	cancel         context.CancelFunc
+	closeOnce      sync.Once
+	store          store.Store
+
+	// staleZones and staleSensors record the time a cached value was marked
+	// stale, either by InvalidateValues or a forced Refresh. The background
+	// refresher started in startStaleRefresher drains these instead of the
+	// cache being dropped immediately, so one invalidation doesn't cause
+	// every subscriber to re-request the same zone from its hub at once.
+	staleZones   map[string]time.Time
+	staleSensors map[string]time.Time
+
+	// refreshQueue feeds the refresh worker goroutines, and inFlight
+	// ensures at most one refresh request is outstanding per zone/sensor
+	// key at a time; see claimRefresh.
+	refreshQueue chan refreshJob
+	inFlight     sync.Map
 }
 
-// NewMonitor returns an initialzed Monitor instance
+// NewMonitor returns an initialzed Monitor instance. poolSize controls how
+// many worker goroutines are available to run MonitorDelegate callbacks, so
+// that a slow delegate only ever blocks the worker handling its own group,
+// not the evtbus consumer goroutine or other subscribers. ctx is the root
+// context for the Monitor's lifetime; canceling it (or calling Close) drains
+// any in-flight events, expires every monitor group and unregisters the
+// Monitor from evtBus. st is used to persist zone and sensor values across
+// restarts, pass store.NewNoop() if that isn't needed. refreshConcurrency
+// bounds how many stale zones/sensors can be refreshed from their hub at the
+// same time.
 func NewMonitor(
+	ctx context.Context,
 	sys *System,
 	evtBus *evtbus.Bus,
 	sensorValues map[string]SensorAttr,
 	zoneValues map[string]cmd.Level,
+	poolSize int,
+	st store.Store,
+	refreshConcurrency int,
 ) *Monitor {
 
 	// Callers can pass in initial values if they know what they are
@@ -66,6 +101,11 @@ func NewMonitor(
 	if zoneValues == nil {
 		zoneValues = make(map[string]cmd.Level)
 	}
+	if st == nil {
+		st = store.NewNoop()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
 	m := &Monitor{
 		system:         sys,
 		nextID:         1,
@@ -75,27 +115,54 @@ func NewMonitor(
 		sensorValues:   sensorValues,
 		zoneValues:     zoneValues,
 		evtBus:         evtBus,
+		pool:           newDispatchPool(ctx, poolSize, defaultGroupQueueSize),
+		verbose:        newVerboseTally(ctx, verboseTallyInterval),
+		ctx:            ctx,
+		cancel:         cancel,
+		store:          st,
+		staleZones:     make(map[string]time.Time),
+		staleSensors:   make(map[string]time.Time),
 	}
 
-	m.handleTimeouts()
+	m.handleTimeouts(ctx)
+	m.watchStore(ctx)
+	m.startStaleRefresher(ctx, refreshConcurrency)
 	evtBus.AddConsumer(m)
 	evtBus.AddProducer(m)
 	return m
 }
 
+// Close cancels the Monitor's internal context. This drains any events left
+// on the consumer channel, expires every monitor group so delegates are told
+// their subscription ended, stops handleTimeouts, and unregisters the
+// Monitor from the event bus. It is safe to call more than once.
+func (m *Monitor) Close() {
+	m.closeOnce.Do(m.cancel)
+}
+
 // Refresh causes the monitor to report the current values for any item in the
 // monitor group, specified by the monitorID parameter.  If force is true then
-// the current cached values stored in the monitor are ignored and new values are
-// requested
+// the monitor first blocks until a fresh value has been fetched from the
+// owning hub for every item in the group, coalescing with any refresh already
+// in flight for the same zone/sensor, or until refreshWaitTimeout passes or
+// the Monitor is closed, after which it reports whatever is cached. The
+// previous cached value is still used
+// to answer other callers while a refresh is pending, so UIs keep rendering
+// the last-known value instead of seeing a gap.
 func (m *Monitor) Refresh(monitorID string, force bool) {
 	m.mutex.RLock()
 	group, ok := m.groups[monitorID]
+	m.mutex.RUnlock()
 
 	if !ok {
-		m.mutex.RUnlock()
 		return
 	}
 
+	if force {
+		m.refreshAndWait(group)
+	}
+
+	m.mutex.RLock()
 	var changeBatch = &ChangeBatch{
 		MonitorID: monitorID,
 		Sensors:   make(map[string]SensorAttr),
@@ -107,7 +174,7 @@ func (m *Monitor) Refresh(monitorID string, force bool) {
 	var sensorReport = &SensorsReport{}
 	for sensorID := range group.Sensors {
 		val, ok := m.sensorValues[sensorID]
-		if ok && !force {
+		if ok {
 			changeBatch.Sensors[sensorID] = val
 		} else {
 			sensorReport.Add(sensorID)
@@ -117,7 +184,7 @@ func (m *Monitor) Refresh(monitorID string, force bool) {
 	var zoneReport = &ZonesReport{}
 	for zoneID := range group.Zones {
 		val, ok := m.zoneValues[zoneID]
-		if ok && !force {
+		if ok {
 			changeBatch.Zones[zoneID] = val
 		} else {
 			zoneReport.Add(zoneID)
@@ -125,26 +192,40 @@ func (m *Monitor) Refresh(monitorID string, force bool) {
 	}
 	m.mutex.RUnlock()
 
-	fmt.Println("refreshing ...")
+	if verboseEventsEnabled {
+		log.V("refreshing ...")
+	}
 	if len(changeBatch.Sensors) > 0 || len(changeBatch.Zones) > 0 {
 		// We have some values already cached for certain items, return
-		fmt.Println("short circuit")
-		group.Handler.Update(changeBatch)
+		if verboseEventsEnabled {
+			log.V("short circuit")
+		}
+		m.pool.dispatch(monitorID, func() {
+			group.Handler.Update(changeBatch)
+		})
 	}
 	if len(sensorReport.SensorIDs) > 0 {
 		// Need to request these sensor values
-		fmt.Println("sensors requesting")
+		if verboseEventsEnabled {
+			log.V("sensors requesting")
+		}
 		m.evtBus.Enqueue(sensorReport)
 	}
 	if len(zoneReport.ZoneIDs) > 0 {
 		// Need to request these zone values
-		fmt.Println("zones requesting")
+		if verboseEventsEnabled {
+			log.V("zones requesting")
+		}
 		m.evtBus.Enqueue(zoneReport)
 	}
 }
 
-// InvalidateValues removes any cached values, for zones and sensors listed
-// in the monitor group
+// InvalidateValues marks any cached values, for zones and sensors listed in
+// the monitor group, as stale. The previous value stays in the cache, so
+// callers keep seeing the last-known value, until the background refresher
+// has fetched a replacement from the owning hub - dropping the value here
+// instead would mean every subscriber re-requests the same zone/sensor from
+// its hub at once.
 func (m *Monitor) InvalidateValues(monitorID string) {
 	m.mutex.RLock()
 	group, ok := m.groups[monitorID]
@@ -154,12 +235,13 @@ func (m *Monitor) InvalidateValues(monitorID string) {
 		return
 	}
 
+	now := time.Now()
 	m.mutex.Lock()
 	for sensorID := range group.Sensors {
-		delete(m.sensorValues, sensorID)
+		m.staleSensors[sensorID] = now
 	}
 	for zoneID := range group.Zones {
-		delete(m.zoneValues, zoneID)
+		m.staleZones[zoneID] = now
 	}
 	m.mutex.Unlock()
 }
@@ -232,6 +314,16 @@ func (m *Monitor) Subscribe(g *MonitorGroup, refresh bool) (string, error) {
 	}
 	m.mutex.Unlock()
 
+	// Hydrate the cache from the store for any value we don't already have
+	// in memory, so a restarted process doesn't have to wait on a hub round
+	// trip before it can report a value for this new group.
+	for sensorID := range g.Sensors {
+		m.hydrateSensor(sensorID)
+	}
+	for zoneID := range g.Zones {
+		m.hydrateZone(zoneID)
+	}
+
 	if refresh {
 		m.Refresh(monitorID, false)
 	}
@@ -245,6 +337,9 @@ func (m *Monitor) Unsubscribe(monitorID string) {
 		return
 	}
 
+	var sensorKeysToDelete []string
+	var zoneKeysToDelete []string
+
 	m.mutex.Lock()
 	delete(m.groups, monitorID)
 	for sensorID, groups := range m.sensorToGroups {
@@ -253,6 +348,8 @@ func (m *Monitor) Unsubscribe(monitorID string) {
 			if len(groups) == 0 {
 				delete(m.sensorToGroups, sensorID)
 				delete(m.sensorValues, sensorID)
+				delete(m.staleSensors, sensorID)
+				sensorKeysToDelete = append(sensorKeysToDelete, sensorKey(sensorID))
 			}
 		}
 	}
@@ -265,10 +362,28 @@ func (m *Monitor) Unsubscribe(monitorID string) {
 			if len(groups) == 0 {
 				delete(m.zoneToGroups, zoneID)
 				delete(m.zoneValues, zoneID)
+				delete(m.staleZones, zoneID)
+				zoneKeysToDelete = append(zoneKeysToDelete, zoneKey(zoneID))
 			}
 		}
 	}
 	m.mutex.Unlock()
+
+	// monitorID is also the dispatchPool's groupID for this group, and
+	// group IDs are never reused, so tell the pool to forget it now rather
+	// than leaving its running/dropped bookkeeping behind forever.
+	m.pool.Forget(monitorID)
+
+	// Delete from the store outside the lock: for the bbolt store this is a
+	// synchronous disk write, and doing it under m.mutex would stall every
+	// other Monitor operation (see persistSensor/persistZone, which follow
+	// the same pattern).
+	for _, key := range sensorKeysToDelete {
+		m.store.Delete(key)
+	}
+	for _, key := range zoneKeysToDelete {
+		m.store.Delete(key)
+	}
 }
 
 func (m *Monitor) sensorAttrChanged(sensorID string, attr SensorAttr) {
@@ -285,16 +400,23 @@ func (m *Monitor) sensorAttrChanged(sensorID string, attr SensorAttr) {
 	m.mutex.RLock()
 	currentVal, ok := m.sensorValues[sensorID]
 	m.mutex.RUnlock()
-	if ok {
-		// No change, don't refresh clients
-		if currentVal.Value == attr.Value {
-			return
-		}
-	}
+	unchanged := ok && currentVal.Value == attr.Value
 
 	m.mutex.Lock()
 	m.sensorValues[sensorID] = attr
+	delete(m.staleSensors, sensorID)
 	m.mutex.Unlock()
+	m.persistSensor(sensorID, attr)
+
+	// A refresh may have been blocking on this value even though it came
+	// back unchanged, let it proceed before deciding whether to notify
+	// subscribers.
+	m.completeRefresh(sensorKey(sensorID))
+
+	if unchanged {
+		// No change, don't refresh clients
+		return
+	}
 
 	for groupID := range groups {
 		m.mutex.RLock()
@@ -305,17 +427,23 @@ func (m *Monitor) sensorAttrChanged(sensorID string, attr SensorAttr) {
 		}
 		cb.Sensors[sensorID] = attr
 		m.mutex.RUnlock()
-		group.Handler.Update(cb)
+		m.pool.dispatch(groupID, func() {
+			group.Handler.Update(cb)
+		})
 	}
 }
 
 func (m *Monitor) zoneLevelChanged(zoneID string, val cmd.Level) {
-	fmt.Println("zlc")
+	if verboseEventsEnabled {
+		log.V("zlc")
+	}
 	m.mutex.RLock()
 	groups, ok := m.zoneToGroups[zoneID]
 	m.mutex.RUnlock()
 	if !ok {
-		fmt.Println("zlc - exit 1")
+		if verboseEventsEnabled {
+			log.V("zlc - exit 1")
+		}
 		return
 	}
 
@@ -323,19 +451,30 @@ func (m *Monitor) zoneLevelChanged(zoneID string, val cmd.Level) {
 	m.mutex.RLock()
 	currentVal, ok := m.zoneValues[zoneID]
 	m.mutex.RUnlock()
-	if ok {
-		// No change, don't refresh clients
-		if currentVal == val {
-			fmt.Println("zlc - exit 2")
-			return
-		}
-	}
+	unchanged := ok && currentVal == val
 
 	m.mutex.Lock()
 	m.zoneValues[zoneID] = val
+	delete(m.staleZones, zoneID)
 	m.mutex.Unlock()
+	m.persistZone(zoneID, val)
+
+	// A refresh may have been blocking on this value even though it came
+	// back unchanged, let it proceed before deciding whether to notify
+	// subscribers.
+	m.completeRefresh(zoneKey(zoneID))
+
+	if unchanged {
+		// No change, don't refresh clients
+		if verboseEventsEnabled {
+			log.V("zlc - exit 2")
+		}
+		return
+	}
 
-	fmt.Println("zlc - go")
+	if verboseEventsEnabled {
+		log.V("zlc - go")
+	}
 	for groupID := range groups {
 		m.mutex.RLock()
 		group := m.groups[groupID]
@@ -345,15 +484,23 @@ func (m *Monitor) zoneLevelChanged(zoneID string, val cmd.Level) {
 		}
 		cb.Zones[zoneID] = val
 		m.mutex.RUnlock()
-		group.Handler.Update(cb)
+		m.pool.dispatch(groupID, func() {
+			group.Handler.Update(cb)
+		})
 	}
 }
 
 // handleTimeouts watches for monitor groups that have expired and purges them
-// from the system
-func (m *Monitor) handleTimeouts() {
+// from the system, until ctx is canceled
+func (m *Monitor) handleTimeouts(ctx context.Context) {
 	go func() {
 		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second * 5):
+			}
+
 			now := time.Now()
 			var expired []*MonitorGroup
 			m.mutex.RLock()
@@ -368,9 +515,6 @@ func (m *Monitor) handleTimeouts() {
 				m.Unsubscribe(group.id)
 				group.Handler.Expired(group.id)
 			}
-
-			// Sleep then wake up and check again for the next expired items
-			time.Sleep(time.Second * 5)
 		}
 	}()
 }
@@ -391,33 +535,73 @@ func (m *Monitor) StartConsuming(c chan evtbus.Event) {
 	log.V("Monitor - start consuming events")
 
 	go func() {
-		for e := range c {
-			switch evt := e.(type) {
-			case *SensorAttrChanged:
-				log.V("Monitor - processing SensorAttrChanged event")
-				m.sensorAttrChanged(evt.SensorID, evt.Attr)
-
-			case *SensorsReporting:
-				for sensorID, attr := range evt.Sensors {
-					m.sensorAttrChanged(sensorID, attr)
+		for {
+			select {
+			case <-m.ctx.Done():
+				m.shutdown(c)
+				return
+
+			case e, ok := <-c:
+				if !ok {
+					log.V("Monitor - event channel has closed")
+					return
 				}
 
-			case *ZonesReporting:
-				for zoneID, val := range evt.Zones {
-					m.zoneLevelChanged(zoneID, val)
+				if !verboseEventsEnabled {
+					m.verbose.track(e)
 				}
 
-			case *ZoneLevelChanged:
-				m.zoneLevelChanged(evt.ZoneID, evt.Level)
-			}
+				switch evt := e.(type) {
+				case *SensorAttrChanged:
+					log.V("Monitor - processing SensorAttrChanged event")
+					m.sensorAttrChanged(evt.SensorID, evt.Attr)
+
+				case *SensorsReporting:
+					for sensorID, attr := range evt.Sensors {
+						m.sensorAttrChanged(sensorID, attr)
+					}
+
+				case *ZonesReporting:
+					for zoneID, val := range evt.Zones {
+						m.zoneLevelChanged(zoneID, val)
+					}
 
+				case *ZoneLevelChanged:
+					m.zoneLevelChanged(evt.ZoneID, evt.Level)
+				}
+			}
 		}
-		log.V("Monitor - event channel has closed")
 	}()
 }
 
+// shutdown drains any events left on c, expires every monitor group so
+// delegates are told their subscription ended, and unregisters the Monitor
+// from the event bus. It runs once m.ctx has been canceled.
+func (m *Monitor) shutdown(c chan evtbus.Event) {
+	for {
+		select {
+		case <-c:
+		default:
+			m.mutex.RLock()
+			expired := make([]*MonitorGroup, 0, len(m.groups))
+			for _, group := range m.groups {
+				expired = append(expired, group)
+			}
+			m.mutex.RUnlock()
+
+			for _, group := range expired {
+				group.Handler.Expired(group.id)
+			}
+
+			m.evtBus.RemoveConsumer(m)
+			m.evtBus.RemoveProducer(m)
+			return
+		}
+	}
+}
+
 func (m *Monitor) StopConsuming() {
-	//TODO:
+	m.Close()
 }
 
 // =================================
@@ -428,12 +612,13 @@ func (m *Monitor) ProducerName() string {
 }
 
 func (m *Monitor) StartProducing(evtBus *evtbus.Bus) {
-	//TODO: Delete?
+	//TODO: Delete? If a real producer loop is added in the future it should
+	//select on m.ctx.Done() the same way StartConsuming and handleTimeouts do,
+	//so it shuts down when the Monitor's context is canceled.
 }
 
 func (m *Monitor) StopProducing() {
-	//TODO: if a producer stops producing, do we need to invalidate all of the
-	//values it is responsible for since they will not longer be updated??
+	m.Close()
 }
 
 // ==================================
@@ -0,0 +1,201 @@
+package gohome
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/markdaws/gohome/log"
+)
+
+// defaultGroupQueueSize is the number of pending delegate callbacks a single
+// monitor group is allowed to back up before the oldest one is dropped.
+const defaultGroupQueueSize = 32
+
+// dispatchReadyBuffer bounds how many distinct groups can have work waiting
+// for a free worker at once. Sized generously so the common case of
+// handing a group off to a worker never blocks the caller, which is
+// usually the evtbus consumer goroutine.
+const dispatchReadyBuffer = 1024
+
+// dispatchReportInterval is how often the pool logs its aggregated queue
+// depth and drop counts.
+const dispatchReportInterval = time.Minute
+
+// dispatchPool is a bounded pool of worker goroutines used to run
+// MonitorDelegate callbacks off of the evtbus consumer goroutine, so a slow
+// HTTP/WebSocket delegate can't block ChangeBatch delivery to every other
+// subscriber. Callbacks for a given group are always run in the order they
+// were dispatched, so a client never sees an out-of-order ChangeBatch, and
+// one group backed up behind a slow delegate can't block another group's
+// callbacks from being handed to a free worker.
+type dispatchPool struct {
+	ready    chan string
+	maxQueue int
+
+	mutex   sync.Mutex
+	queues  map[string][]func()
+	running map[string]bool
+	dropped map[string]int64
+}
+
+// newDispatchPool starts size worker goroutines and returns a pool ready to
+// have work dispatched to it. maxQueue bounds how many callbacks can be
+// backlogged for a single group before the oldest is dropped. Workers and
+// the periodic stats logger run until ctx is canceled.
+func newDispatchPool(ctx context.Context, size, maxQueue int) *dispatchPool {
+	if size <= 0 {
+		size = 1
+	}
+	if maxQueue <= 0 {
+		maxQueue = defaultGroupQueueSize
+	}
+
+	p := &dispatchPool{
+		ready:    make(chan string, dispatchReadyBuffer),
+		maxQueue: maxQueue,
+		queues:   make(map[string][]func()),
+		running:  make(map[string]bool),
+		dropped:  make(map[string]int64),
+	}
+	for i := 0; i < size; i++ {
+		go p.worker(ctx)
+	}
+	go p.reportLoop(ctx)
+	return p
+}
+
+func (p *dispatchPool) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case groupID := <-p.ready:
+			p.drain(groupID)
+		}
+	}
+}
+
+// drain runs every callback queued for groupID, in order, until the
+// backlog is empty. It deletes groupID's running entry rather than just
+// clearing it, so a group that goes idle doesn't leave a permanent map
+// entry behind; dropped is left alone since it's a cumulative count for the
+// group's whole lifetime, not just the current drain (see Forget).
+func (p *dispatchPool) drain(groupID string) {
+	for {
+		p.mutex.Lock()
+		q := p.queues[groupID]
+		if len(q) == 0 {
+			delete(p.queues, groupID)
+			delete(p.running, groupID)
+			p.mutex.Unlock()
+			return
+		}
+		next := q[0]
+		p.queues[groupID] = q[1:]
+		p.mutex.Unlock()
+
+		next()
+	}
+}
+
+// dispatch queues fn to run for groupID. If a callback is already running or
+// queued for groupID, fn is appended to that group's backlog instead of
+// being handed to a worker immediately, so groupID's callbacks always run in
+// dispatch order. If the backlog is already at maxQueue, the oldest queued
+// callback is dropped to make room, so one hung delegate can't exhaust the
+// pool's backlog memory.
+//
+// Handing groupID off to a worker never blocks the caller: if every worker
+// is already busy draining other groups and the ready channel is momentarily
+// full, the handoff is retried on a throwaway goroutine instead of on the
+// caller, which is usually the evtbus consumer goroutine and must never
+// stall because of a slow delegate on some other group.
+func (p *dispatchPool) dispatch(groupID string, fn func()) {
+	p.mutex.Lock()
+	q := p.queues[groupID]
+	if len(q) >= p.maxQueue {
+		q = q[1:]
+		p.dropped[groupID]++
+	}
+	p.queues[groupID] = append(q, fn)
+
+	alreadyRunning := p.running[groupID]
+	p.running[groupID] = true
+	p.mutex.Unlock()
+
+	if alreadyRunning {
+		return
+	}
+
+	select {
+	case p.ready <- groupID:
+	default:
+		go func() { p.ready <- groupID }()
+	}
+}
+
+// QueueDepth returns the number of callbacks currently backlogged for
+// groupID, not counting one that may currently be executing.
+func (p *dispatchPool) QueueDepth(groupID string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.queues[groupID])
+}
+
+// Dropped returns the number of callbacks that have been dropped for
+// groupID because its backlog exceeded maxQueue.
+func (p *dispatchPool) Dropped(groupID string) int64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.dropped[groupID]
+}
+
+// Forget releases all bookkeeping the pool still holds for groupID,
+// including its cumulative dropped count. Callers should call this once
+// groupID is gone for good, e.g. from Monitor.Unsubscribe - monitor group
+// IDs are never reused, so without this the dropped/running maps would
+// otherwise grow by one entry per subscription for the life of the process.
+func (p *dispatchPool) Forget(groupID string) {
+	p.mutex.Lock()
+	delete(p.queues, groupID)
+	delete(p.running, groupID)
+	delete(p.dropped, groupID)
+	p.mutex.Unlock()
+}
+
+// reportLoop periodically logs the pool's aggregated queue depth and drop
+// counts, so a backed-up or lossy pool is actually observable somewhere
+// instead of only through QueueDepth/Dropped, which nothing else calls.
+func (p *dispatchPool) reportLoop(ctx context.Context) {
+	ticker := time.NewTicker(dispatchReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.logStats()
+		}
+	}
+}
+
+func (p *dispatchPool) logStats() {
+	p.mutex.Lock()
+	var totalDepth int
+	var totalDropped int64
+	for _, q := range p.queues {
+		totalDepth += len(q)
+	}
+	for _, n := range p.dropped {
+		totalDropped += n
+	}
+	p.mutex.Unlock()
+
+	if totalDepth == 0 && totalDropped == 0 {
+		return
+	}
+	log.V(fmt.Sprintf("monitor dispatch pool: queue depth=%d dropped=%d", totalDepth, totalDropped))
+}
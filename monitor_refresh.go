@@ -0,0 +1,291 @@
+package gohome
+
+import (
+	"context"
+	"time"
+)
+
+// staleScanInterval is how often the background refresher checks for stale
+// zones and sensors that need a new value requested from their hub.
+const staleScanInterval = time.Second
+
+// refreshWaitTimeout bounds how long a forced Refresh blocks waiting, for
+// all of its group's zones and sensors together, on their hub round trips
+// before giving up and falling back to whatever is already in the cache, so
+// an unresponsive hub can't hang a caller forever.
+const refreshWaitTimeout = 10 * time.Second
+
+// refreshJob is a unit of work handed to a refresh worker: enqueue issues a
+// single batched ZonesReport/SensorsReport request covering every key in
+// keys, and keys lets submitJob release their in-flight markers if the job
+// is dropped instead of run.
+type refreshJob struct {
+	keys    []string
+	enqueue func()
+}
+
+// inFlightExpiry bounds how long a key can sit claimed in m.inFlight
+// without its hub ever answering. Apart from submitJob releasing the keys of
+// a job it had to drop, completeRefresh is only called from
+// zoneLevelChanged/sensorAttrChanged when a value actually arrives, so a
+// hub that never answers at all would otherwise hold its claim forever -
+// every later scan would see claimRefresh return ok=false for it and never
+// ask again. expireInFlight releases any claim older than this so a
+// transiently-dead hub gets retried instead of permanently skipped.
+const inFlightExpiry = 30 * time.Second
+
+// inFlightRefresh is the value stored in m.inFlight: done is closed once
+// the key's refresh completes or its claim expires, submittedAt is when it
+// was claimed, used by expireInFlight.
+type inFlightRefresh struct {
+	done        chan struct{}
+	submittedAt time.Time
+}
+
+// startStaleRefresher starts concurrency worker goroutines that drain
+// m.staleZones/m.staleSensors, plus the ticker that feeds them, until ctx is
+// canceled.
+func (m *Monitor) startStaleRefresher(ctx context.Context, concurrency int) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	m.refreshQueue = make(chan refreshJob, concurrency*4)
+
+	for i := 0; i < concurrency; i++ {
+		go m.refreshWorker(ctx)
+	}
+
+	go func() {
+		ticker := time.NewTicker(staleScanInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.expireInFlight()
+				m.enqueueStaleKeys()
+			}
+		}
+	}()
+}
+
+func (m *Monitor) refreshWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-m.refreshQueue:
+			job.enqueue()
+		}
+	}
+}
+
+// enqueueStaleKeys submits one batched refresh job for every currently
+// stale zone and one for every currently stale sensor that doesn't already
+// have a refresh in flight, so a mass InvalidateValues turns into a single
+// ZonesReport and a single SensorsReport per scan instead of one report per
+// key.
+func (m *Monitor) enqueueStaleKeys() {
+	m.mutex.RLock()
+	zoneIDs := make([]string, 0, len(m.staleZones))
+	for id := range m.staleZones {
+		zoneIDs = append(zoneIDs, id)
+	}
+	sensorIDs := make([]string, 0, len(m.staleSensors))
+	for id := range m.staleSensors {
+		sensorIDs = append(sensorIDs, id)
+	}
+	m.mutex.RUnlock()
+
+	m.submitZoneRefreshes(zoneIDs)
+	m.submitSensorRefreshes(sensorIDs)
+}
+
+// submitZoneRefreshes claims every zoneID that doesn't already have a
+// refresh in flight and submits them all as a single batched ZonesReport
+// job. It returns the in-flight channel for each requested zoneID,
+// including ones it didn't claim because a refresh was already running.
+func (m *Monitor) submitZoneRefreshes(zoneIDs []string) map[string]chan struct{} {
+	waits := make(map[string]chan struct{}, len(zoneIDs))
+	var claimed []string
+	var keys []string
+	for _, zoneID := range zoneIDs {
+		done, ok := m.claimRefresh(zoneKey(zoneID))
+		waits[zoneID] = done
+		if ok {
+			claimed = append(claimed, zoneID)
+			keys = append(keys, zoneKey(zoneID))
+		}
+	}
+	if len(claimed) == 0 {
+		return waits
+	}
+
+	m.submitJob(refreshJob{
+		keys: keys,
+		enqueue: func() {
+			report := &ZonesReport{}
+			for _, zoneID := range claimed {
+				report.Add(zoneID)
+			}
+			m.evtBus.Enqueue(report)
+		},
+	})
+	return waits
+}
+
+// submitSensorRefreshes is submitZoneRefreshes for sensors; see its
+// comment.
+func (m *Monitor) submitSensorRefreshes(sensorIDs []string) map[string]chan struct{} {
+	waits := make(map[string]chan struct{}, len(sensorIDs))
+	var claimed []string
+	var keys []string
+	for _, sensorID := range sensorIDs {
+		done, ok := m.claimRefresh(sensorKey(sensorID))
+		waits[sensorID] = done
+		if ok {
+			claimed = append(claimed, sensorID)
+			keys = append(keys, sensorKey(sensorID))
+		}
+	}
+	if len(claimed) == 0 {
+		return waits
+	}
+
+	m.submitJob(refreshJob{
+		keys: keys,
+		enqueue: func() {
+			report := &SensorsReport{}
+			for _, sensorID := range claimed {
+				report.Add(sensorID)
+			}
+			m.evtBus.Enqueue(report)
+		},
+	})
+	return waits
+}
+
+// claimRefresh reports whether the caller is now responsible for triggering
+// a refresh of key: ok is true if nobody else already has one in flight,
+// in which case the caller must make sure the job it builds eventually gets
+// submitted. Either way the returned channel is closed once key's refresh
+// completes or its claim expires (see completeRefresh and expireInFlight),
+// so callers that lose the claim still coalesce onto the winning refresh.
+func (m *Monitor) claimRefresh(key string) (done chan struct{}, ok bool) {
+	entry := &inFlightRefresh{done: make(chan struct{}), submittedAt: time.Now()}
+	actual, loaded := m.inFlight.LoadOrStore(key, entry)
+	if loaded {
+		return actual.(*inFlightRefresh).done, false
+	}
+	return entry.done, true
+}
+
+// submitJob hands job to a refresh worker. If the refresher is saturated,
+// job is dropped and every key it claimed is released so the next scan
+// retries them instead of waiting on a job that was never queued.
+//
+// completeRefresh is also what releases a claim when the job actually runs
+// but the hub never reports a value back (see expireInFlight) - either way,
+// a caller blocked in awaitRefresh sees its wait unblock as "done" even
+// though no report was issued and the cache wasn't updated. That's
+// intentional: it's strictly better than blocking forever, but it does mean
+// the caller may get back a stale cached value rather than a fresh one.
+func (m *Monitor) submitJob(job refreshJob) {
+	select {
+	case m.refreshQueue <- job:
+	default:
+		for _, key := range job.keys {
+			m.completeRefresh(key)
+		}
+	}
+}
+
+// completeRefresh removes key's in-flight claim and releases any callers
+// blocked in awaitRefresh on the channel claimRefresh returned for key.
+func (m *Monitor) completeRefresh(key string) {
+	v, ok := m.inFlight.LoadAndDelete(key)
+	if !ok {
+		return
+	}
+	close(v.(*inFlightRefresh).done)
+}
+
+// expireInFlight releases the in-flight claim on any key whose hub hasn't
+// answered within inFlightExpiry, so it can be claimed and retried by a
+// later scan instead of being stuck in flight forever.
+func (m *Monitor) expireInFlight() {
+	now := time.Now()
+	var expired []string
+	m.inFlight.Range(func(k, v interface{}) bool {
+		if now.Sub(v.(*inFlightRefresh).submittedAt) >= inFlightExpiry {
+			expired = append(expired, k.(string))
+		}
+		return true
+	})
+	for _, key := range expired {
+		m.completeRefresh(key)
+	}
+}
+
+// refreshAndWait marks every zone and sensor in group stale and blocks until
+// the corresponding hub round trip has refreshed the cache, coalescing with
+// any refresh already in flight for the same keys so concurrent force
+// refreshes of overlapping groups only hit the hub once per key. It stops
+// waiting on all remaining keys once refreshWaitTimeout has passed since the
+// refreshes were submitted, or m.ctx is canceled, leaving the
+// caller to fall back to whatever value is already cached rather than
+// blocking forever on a hub that never answers.
+func (m *Monitor) refreshAndWait(group *MonitorGroup) {
+	now := time.Now()
+	m.mutex.Lock()
+	for sensorID := range group.Sensors {
+		m.staleSensors[sensorID] = now
+	}
+	for zoneID := range group.Zones {
+		m.staleZones[zoneID] = now
+	}
+	m.mutex.Unlock()
+
+	sensorIDs := make([]string, 0, len(group.Sensors))
+	for sensorID := range group.Sensors {
+		sensorIDs = append(sensorIDs, sensorID)
+	}
+	zoneIDs := make([]string, 0, len(group.Zones))
+	for zoneID := range group.Zones {
+		zoneIDs = append(zoneIDs, zoneID)
+	}
+
+	sensorWaits := m.submitSensorRefreshes(sensorIDs)
+	zoneWaits := m.submitZoneRefreshes(zoneIDs)
+
+	deadline := time.Now().Add(refreshWaitTimeout)
+	for _, w := range sensorWaits {
+		if !m.awaitRefresh(w, deadline) {
+			return
+		}
+	}
+	for _, w := range zoneWaits {
+		if !m.awaitRefresh(w, deadline) {
+			return
+		}
+	}
+}
+
+// awaitRefresh blocks until w is closed, m.ctx is canceled or deadline
+// passes, whichever happens first. It reports whether w was actually
+// closed; the caller should stop waiting on the remaining keys and fall
+// back to the cache as soon as it sees false.
+func (m *Monitor) awaitRefresh(w chan struct{}, deadline time.Time) bool {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-w:
+		return true
+	case <-m.ctx.Done():
+		return false
+	case <-timer.C:
+		return false
+	}
+}
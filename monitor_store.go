@@ -0,0 +1,153 @@
+package gohome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/markdaws/gohome/cmd"
+	"github.com/markdaws/gohome/log"
+	"github.com/markdaws/gohome/store"
+)
+
+// zoneKey returns the namespaced key used to persist a zone's cached value.
+func zoneKey(zoneID string) string {
+	return "zone/" + zoneID
+}
+
+// sensorKey returns the namespaced key used to persist a sensor's cached
+// value.
+func sensorKey(sensorID string) string {
+	return "sensor/" + sensorID
+}
+
+// hydrateSensor loads sensorID's last persisted value from m.store, if any,
+// into m.sensorValues so a freshly subscribed client doesn't have to wait on
+// a hub round trip for a value this process already knew before it
+// restarted.
+func (m *Monitor) hydrateSensor(sensorID string) {
+	raw, ok, err := m.store.Get(sensorKey(sensorID))
+	if err != nil || !ok {
+		return
+	}
+
+	var val SensorAttr
+	if err := json.Unmarshal(raw, &val); err != nil {
+		log.V(fmt.Sprintf("Monitor - failed to decode persisted sensor value for %s: %s", sensorID, err))
+		return
+	}
+
+	m.mutex.Lock()
+	if _, exists := m.sensorValues[sensorID]; !exists {
+		m.sensorValues[sensorID] = val
+	}
+	m.mutex.Unlock()
+}
+
+// hydrateZone loads zoneID's last persisted value from m.store, if any, into
+// m.zoneValues.
+func (m *Monitor) hydrateZone(zoneID string) {
+	raw, ok, err := m.store.Get(zoneKey(zoneID))
+	if err != nil || !ok {
+		return
+	}
+
+	var val cmd.Level
+	if err := json.Unmarshal(raw, &val); err != nil {
+		log.V(fmt.Sprintf("Monitor - failed to decode persisted zone value for %s: %s", zoneID, err))
+		return
+	}
+
+	m.mutex.Lock()
+	if _, exists := m.zoneValues[zoneID]; !exists {
+		m.zoneValues[zoneID] = val
+	}
+	m.mutex.Unlock()
+}
+
+// persistSensor writes sensorID's current value to m.store so it survives a
+// restart.
+func (m *Monitor) persistSensor(sensorID string, val SensorAttr) {
+	raw, err := json.Marshal(val)
+	if err != nil {
+		log.V(fmt.Sprintf("Monitor - failed to encode sensor value for %s: %s", sensorID, err))
+		return
+	}
+	if err := m.store.Put(sensorKey(sensorID), raw); err != nil {
+		log.V(fmt.Sprintf("Monitor - failed to persist sensor value for %s: %s", sensorID, err))
+	}
+}
+
+// persistZone writes zoneID's current value to m.store so it survives a
+// restart.
+func (m *Monitor) persistZone(zoneID string, val cmd.Level) {
+	raw, err := json.Marshal(val)
+	if err != nil {
+		log.V(fmt.Sprintf("Monitor - failed to encode zone value for %s: %s", zoneID, err))
+		return
+	}
+	if err := m.store.Put(zoneKey(zoneID), raw); err != nil {
+		log.V(fmt.Sprintf("Monitor - failed to persist zone value for %s: %s", zoneID, err))
+	}
+}
+
+// watchStore listens for Events from m.store until ctx is canceled and
+// applies each one to the in-memory cache: a Put stores the decoded value
+// for its zone/sensor key, whether or not this Monitor had that key cached,
+// and a Delete drops it. Which mutations are reported, including whether
+// ones made by another process sharing the store are, depends on the Store's
+// Watch implementation.
+func (m *Monitor) watchStore(ctx context.Context) {
+	go func() {
+		for evt := range m.store.Watch(ctx) {
+			switch evt.Type {
+			case store.Put:
+				m.applyStoreEvent(evt)
+			case store.Delete:
+				m.removeStoreKey(evt.Key)
+			case store.ConnectionDown:
+				log.V("Monitor - store connection down, cached values may be stale")
+			}
+		}
+	}()
+}
+
+func (m *Monitor) applyStoreEvent(evt store.Event) {
+	switch {
+	case len(evt.Key) > len("zone/") && evt.Key[:len("zone/")] == "zone/":
+		var val cmd.Level
+		if err := json.Unmarshal(evt.Value, &val); err != nil {
+			return
+		}
+		zoneID := evt.Key[len("zone/"):]
+		m.mutex.Lock()
+		m.zoneValues[zoneID] = val
+		m.mutex.Unlock()
+
+	case len(evt.Key) > len("sensor/") && evt.Key[:len("sensor/")] == "sensor/":
+		var val SensorAttr
+		if err := json.Unmarshal(evt.Value, &val); err != nil {
+			return
+		}
+		sensorID := evt.Key[len("sensor/"):]
+		m.mutex.Lock()
+		m.sensorValues[sensorID] = val
+		m.mutex.Unlock()
+	}
+}
+
+func (m *Monitor) removeStoreKey(key string) {
+	switch {
+	case len(key) > len("zone/") && key[:len("zone/")] == "zone/":
+		zoneID := key[len("zone/"):]
+		m.mutex.Lock()
+		delete(m.zoneValues, zoneID)
+		m.mutex.Unlock()
+
+	case len(key) > len("sensor/") && key[:len("sensor/")] == "sensor/":
+		sensorID := key[len("sensor/"):]
+		m.mutex.Lock()
+		delete(m.sensorValues, sensorID)
+		m.mutex.Unlock()
+	}
+}
@@ -0,0 +1,88 @@
+package gohome
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/markdaws/gohome/log"
+)
+
+// verboseEventsEnabled mirrors the GOHOME_VERBOSE_EVENTS environment
+// variable. When it's unset the per-event debug traces in Monitor are
+// replaced by the aggregated counters in verboseTally.
+var verboseEventsEnabled = os.Getenv("GOHOME_VERBOSE_EVENTS") != ""
+
+// verboseTallyInterval is how often a verboseTally logs and resets its
+// counters.
+const verboseTallyInterval = time.Minute
+
+// VerboseKey is implemented by events that can fire often enough (e.g.
+// Lutron's ZoneLevelChanged during a fade) that logging one line per
+// occurrence would flood stdout. Instead of logging each event, a
+// verboseTally counts occurrences per key and a single aggregated line is
+// logged on an interval.
+type VerboseKey interface {
+	VerboseKey() string
+}
+
+// verboseTally counts events per VerboseKey and periodically logs and resets
+// the totals. It is safe for concurrent use so any consumer of chatty events
+// can share one.
+type verboseTally struct {
+	mutex  sync.Mutex
+	counts map[string]int64
+}
+
+// newVerboseTally starts the tally's background logger, which runs until
+// ctx is canceled.
+func newVerboseTally(ctx context.Context, interval time.Duration) *verboseTally {
+	t := &verboseTally{counts: make(map[string]int64)}
+	go t.run(ctx, interval)
+	return t
+}
+
+// track increments the counter for evt's VerboseKey. If evt doesn't
+// implement VerboseKey this is a no-op.
+func (t *verboseTally) track(evt interface{}) {
+	vk, ok := evt.(VerboseKey)
+	if !ok {
+		return
+	}
+	t.mutex.Lock()
+	t.counts[vk.VerboseKey()]++
+	t.mutex.Unlock()
+}
+
+func (t *verboseTally) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		t.mutex.Lock()
+		if len(t.counts) == 0 {
+			t.mutex.Unlock()
+			continue
+		}
+
+		line := ""
+		for key, count := range t.counts {
+			if line != "" {
+				line += " "
+			}
+			line += fmt.Sprintf("%s=%d", key, count)
+			delete(t.counts, key)
+		}
+		t.mutex.Unlock()
+
+		log.V(line)
+	}
+}
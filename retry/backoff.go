@@ -0,0 +1,89 @@
+// Package retry provides a small exponential backoff helper used by
+// extension drivers when acquiring connections or issuing requests against
+// hardware that can briefly drop off the network, e.g. a hub resetting
+// during a firmware update.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// ErrPermanent can be wrapped around an error returned by the operation
+// passed to Backoff.Run to signal that retrying won't help, e.g. an auth
+// failure or an unrecognized device model, so Run should stop immediately.
+var ErrPermanent = errors.New("retry: permanent error")
+
+// Backoff retries an operation with exponential delays between attempts,
+// starting at Min and capped at Max, up to MaxRetries times.
+type Backoff struct {
+	Min        time.Duration
+	Max        time.Duration
+	MaxRetries int
+
+	// Jitter adds up to Jitter * delay of random extra wait to each retry,
+	// to avoid many callers retrying the same hub in lockstep.
+	Jitter float64
+
+	cause error
+}
+
+// NewBackoff returns a Backoff configured with the given bounds and a
+// default jitter of up to 50% of the current delay.
+func NewBackoff(min, max time.Duration, maxRetries int) *Backoff {
+	return &Backoff{Min: min, Max: max, MaxRetries: maxRetries, Jitter: 0.5}
+}
+
+// ErrCause returns the last error returned by the operation passed to Run,
+// as opposed to the generic error Run itself returns once its retries are
+// exhausted.
+func (b *Backoff) ErrCause() error {
+	return b.cause
+}
+
+// Run calls op until it succeeds, ctx is canceled, MaxRetries is exceeded, or
+// op returns an error wrapping ErrPermanent. ErrCause reports the last error
+// returned by op regardless of which of those conditions ends the retry
+// loop, so callers that only see Run's returned error can still recover the
+// real underlying cause.
+func (b *Backoff) Run(ctx context.Context, op func() error) error {
+	delay := b.Min
+	if delay <= 0 {
+		delay = 100 * time.Millisecond
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil {
+			b.cause = nil
+			return nil
+		}
+		b.cause = err
+
+		if errors.Is(err, ErrPermanent) {
+			return err
+		}
+		if b.MaxRetries > 0 && attempt >= b.MaxRetries {
+			return fmt.Errorf("retry: exhausted %d retries, last error: %s", b.MaxRetries, err)
+		}
+
+		wait := delay
+		if b.Jitter > 0 {
+			wait += time.Duration(rand.Float64() * b.Jitter * float64(delay))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		delay *= 2
+		if b.Max > 0 && delay > b.Max {
+			delay = b.Max
+		}
+	}
+}
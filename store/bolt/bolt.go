@@ -0,0 +1,123 @@
+// Package bolt implements store.Store on top of an embedded bbolt database,
+// giving gohome.Monitor a durable cache that survives process restarts
+// without requiring an external service.
+package bolt
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/markdaws/gohome/store"
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucketName = []byte("gohome")
+
+// Store persists key/value pairs in a single bbolt database file.
+type Store struct {
+	db *bolt.DB
+
+	mutex    sync.Mutex
+	watchers map[chan store.Event]bool
+}
+
+// New opens (creating if necessary) a bbolt database at path and returns a
+// Store backed by it.
+func New(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("open bbolt db: %s", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create bucket: %s", err)
+	}
+
+	return &Store{
+		db:       db,
+		watchers: make(map[chan store.Event]bool),
+	}, nil
+}
+
+// Put durably stores val under key.
+func (s *Store) Put(key string, val []byte) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Put([]byte(key), val)
+	})
+	if err != nil {
+		return err
+	}
+	s.notify(store.Event{Type: store.Put, Key: key, Value: val})
+	return nil
+}
+
+// Get returns the value stored under key, if any.
+func (s *Store) Get(key string) ([]byte, bool, error) {
+	var val []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketName).Get([]byte(key))
+		if v != nil {
+			val = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return val, val != nil, nil
+}
+
+// Delete removes key from the store.
+func (s *Store) Delete(key string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Delete([]byte(key))
+	})
+	if err != nil {
+		return err
+	}
+	s.notify(store.Event{Type: store.Delete, Key: key})
+	return nil
+}
+
+// Watch returns a channel that emits an Event for every Put/Delete this
+// Store instance makes. The channel is closed once ctx is canceled.
+func (s *Store) Watch(ctx context.Context) <-chan store.Event {
+	ch := make(chan store.Event, 16)
+
+	s.mutex.Lock()
+	s.watchers[ch] = true
+	s.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mutex.Lock()
+		delete(s.watchers, ch)
+		s.mutex.Unlock()
+		close(ch)
+	}()
+
+	return ch
+}
+
+func (s *Store) notify(evt store.Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for ch := range s.watchers {
+		select {
+		case ch <- evt:
+		default:
+			// Watcher is behind, drop the event rather than block a Put or
+			// Delete call on a slow subscriber.
+		}
+	}
+}
+
+// Close releases the underlying bbolt database file.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
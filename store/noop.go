@@ -0,0 +1,34 @@
+package store
+
+import "context"
+
+// noop is a Store that persists nothing. It is the default Monitor falls
+// back to when the caller doesn't configure a durable backend.
+type noop struct{}
+
+// NewNoop returns a Store that discards everything written to it, Get always
+// reports no value found, and Watch never emits an Event.
+func NewNoop() Store {
+	return &noop{}
+}
+
+func (s *noop) Put(key string, val []byte) error {
+	return nil
+}
+
+func (s *noop) Get(key string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (s *noop) Delete(key string) error {
+	return nil
+}
+
+func (s *noop) Watch(ctx context.Context) <-chan Event {
+	ch := make(chan Event)
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+	return ch
+}
@@ -0,0 +1,49 @@
+// Package store defines the persistence interface used by long running
+// components, such as gohome.Monitor, to cache small pieces of state across
+// restarts.
+package store
+
+import "context"
+
+// EventType describes the kind of change a Watch channel reports.
+type EventType int
+
+const (
+	// Put indicates a key was created or updated.
+	Put EventType = iota
+	// Delete indicates a key was removed.
+	Delete
+	// ConnectionDown indicates the store lost its connection to its
+	// backend; any value read before this event should be treated as
+	// stale until a new Event for the same key arrives.
+	ConnectionDown
+)
+
+// Event is delivered on a Store's Watch channel whenever a key changes,
+// including changes made by another process sharing the same store, or when
+// the store's connection to its backend is lost.
+type Event struct {
+	Type  EventType
+	Key   string
+	Value []byte
+}
+
+// Store is implemented by anything that can durably persist the small
+// key/value pairs gohome.Monitor uses to cache zone and sensor values.
+type Store interface {
+	// Put durably stores val under key.
+	Put(key string, val []byte) error
+
+	// Get returns the value stored under key. The second return value is
+	// false if no value has been stored for key.
+	Get(key string) ([]byte, bool, error)
+
+	// Delete removes key from the store. It is not an error to delete a
+	// key that doesn't exist.
+	Delete(key string) error
+
+	// Watch returns a channel that emits an Event whenever a key changes in
+	// the store, or the store loses its connection to its backend.
+	// The channel is closed once ctx is canceled.
+	Watch(ctx context.Context) <-chan Event
+}
@@ -7,40 +7,47 @@ const (
 	ZTLight
 	ZTShade
 	ZTSwitch
+	ZTThermostat
+	ZTLock
+	ZTGarage
+	ZTSprinkler
+	ZTFan
+)
 
-	//Garage door
-	//Sprinkler
-	//Heat?
+// typeNames is the single source of truth mapping a Type to the string it is
+// known by. TypeFromString and ToString are both derived from it, so the two
+// can't disagree with each other. A Type with no entry here has ToString
+// "unknown" and is never returned by TypeFromString.
+var typeNames = map[Type]string{
+	ZTUnknown:    "unknown",
+	ZTLight:      "light",
+	ZTShade:      "shade",
+	ZTSwitch:     "switch",
+	ZTThermostat: "thermostat",
+	ZTLock:       "lock",
+	ZTGarage:     "garage",
+	ZTSprinkler:  "sprinkler",
+	ZTFan:        "fan",
+}
 
-	//TODO: What are the most common smart devices, add support
-	//TODO: Document how to add support for a new device
-)
+var namesToType = func() map[string]Type {
+	m := make(map[string]Type, len(typeNames))
+	for t, name := range typeNames {
+		m[name] = t
+	}
+	return m
+}()
 
 func TypeFromString(zt string) Type {
-	switch zt {
-	case "light":
-		return ZTLight
-	case "switch":
-		return ZTSwitch
-	case "shade":
-		return ZTShade
-	case "unknown":
-		return ZTUnknown
-	default:
-		return ZTUnknown
+	if t, ok := namesToType[zt]; ok {
+		return t
 	}
+	return ZTUnknown
 }
 
-//TODO: Gen this automatically
 func (zt Type) ToString() string {
-	switch zt {
-	case ZTLight:
-		return "light"
-	case ZTSwitch:
-		return "switch"
-	case ZTShade:
-		return "shade"
-	default:
-		return "unknown"
+	if name, ok := typeNames[zt]; ok {
+		return name
 	}
+	return "unknown"
 }